@@ -0,0 +1,91 @@
+package migrations
+
+import "fmt"
+
+// DialectQueries dispatches the bootstrap DDL (and, going forward, any
+// per-migration SQL that can't be shared verbatim) to the right syntax for
+// a given backend. The original `tables` strings were written against the
+// lowest common denominator of MySQL/SQLite, which works but leaves
+// Postgres stuck with varchar instead of its native unbounded TEXT type.
+//
+// The timestamp columns (created_at, updated_at, started_at, completed_at)
+// are kept as varchar/text here rather than switched to Postgres TIMESTAMP:
+// this package doesn't contain the datastore code that writes those values,
+// so there's no way to confirm from here that it formats them as something
+// Postgres's timestamp parser accepts. Revisit once that's verified against
+// the actual insert/update call sites.
+type DialectQueries interface {
+	// CreateTableStatements returns the CREATE TABLE IF NOT EXISTS
+	// statements needed to bootstrap the datastore, in dependency order.
+	CreateTableStatements() []string
+}
+
+type mysqlDialect struct{}
+type sqlite3Dialect struct{}
+type postgresDialect struct{}
+
+func (mysqlDialect) CreateTableStatements() []string   { return tables[:] }
+func (sqlite3Dialect) CreateTableStatements() []string { return tables[:] }
+
+func (postgresDialect) CreateTableStatements() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS routes (
+	app_name TEXT NOT NULL,
+	path TEXT NOT NULL,
+	image TEXT NOT NULL,
+	format varchar(16) NOT NULL,
+	memory int NOT NULL,
+	cpus int,
+	timeout int NOT NULL,
+	idle_timeout int NOT NULL,
+	type varchar(16) NOT NULL,
+	headers TEXT NOT NULL,
+	config TEXT NOT NULL,
+	created_at text,
+	updated_at varchar(256),
+	PRIMARY KEY (app_name, path)
+);`,
+
+		`CREATE TABLE IF NOT EXISTS apps (
+	name TEXT NOT NULL PRIMARY KEY,
+	config TEXT NOT NULL,
+	created_at varchar(256),
+	updated_at varchar(256)
+);`,
+
+		`CREATE TABLE IF NOT EXISTS calls (
+	created_at varchar(256) NOT NULL,
+	started_at varchar(256) NOT NULL,
+	completed_at varchar(256) NOT NULL,
+	status varchar(256) NOT NULL,
+	id varchar(256) NOT NULL,
+	app_name TEXT NOT NULL,
+	path TEXT NOT NULL,
+	stats TEXT,
+	error TEXT,
+	PRIMARY KEY (id)
+);`,
+
+		`CREATE TABLE IF NOT EXISTS logs (
+	id varchar(256) NOT NULL PRIMARY KEY,
+	app_name TEXT NOT NULL,
+	log TEXT NOT NULL
+);`,
+	}
+}
+
+// dialectQueriesFor resolves the DialectQueries implementation for a
+// database/sql driver name, i.e. the same string passed to sql.Open and to
+// goose.SetDialect.
+func dialectQueriesFor(driver string) (DialectQueries, error) {
+	switch driver {
+	case "postgres":
+		return postgresDialect{}, nil
+	case "mysql":
+		return mysqlDialect{}, nil
+	case "sqlite3":
+		return sqlite3Dialect{}, nil
+	default:
+		return nil, fmt.Errorf("migrations: unsupported driver %q", driver)
+	}
+}
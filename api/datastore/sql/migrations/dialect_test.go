@@ -0,0 +1,38 @@
+package migrations
+
+import "testing"
+
+func TestDialectQueriesFor(t *testing.T) {
+	cases := []struct {
+		driver  string
+		want    DialectQueries
+		wantErr bool
+	}{
+		{driver: "postgres", want: postgresDialect{}},
+		{driver: "mysql", want: mysqlDialect{}},
+		{driver: "sqlite3", want: sqlite3Dialect{}},
+		{driver: "mssql", wantErr: true},
+		{driver: "", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.driver, func(t *testing.T) {
+			got, err := dialectQueriesFor(tc.driver)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("dialectQueriesFor(%q) = %v, want error", tc.driver, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dialectQueriesFor(%q) unexpected error: %v", tc.driver, err)
+			}
+			if got != tc.want {
+				t.Errorf("dialectQueriesFor(%q) = %#v, want %#v", tc.driver, got, tc.want)
+			}
+			if len(got.CreateTableStatements()) == 0 {
+				t.Errorf("dialectQueriesFor(%q).CreateTableStatements() returned no statements", tc.driver)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose"
+)
+
+// ConfirmDownStep is invoked before each migration DownTo is about to
+// revert, so a caller can veto the step based on row counts or environment
+// (e.g. refuse to run in production). Returning false stops DownTo with a
+// DownToError of kind DownToVetoed.
+type ConfirmDownStep func(ctx context.Context, step *goose.Migration, targetVersion int64) (bool, error)
+
+// DownToErrorKind distinguishes the different ways DownTo can stop without
+// having reverted to targetVersion, so callers can branch on it instead of
+// string-matching an error message.
+type DownToErrorKind string
+
+const (
+	// DownToAlreadyAtTarget means the database is already at or below
+	// targetVersion; nothing was reverted.
+	DownToAlreadyAtTarget DownToErrorKind = "already_at_target"
+	// DownToUnknownTarget means targetVersion isn't a registered migration.
+	DownToUnknownTarget DownToErrorKind = "unknown_target"
+	// DownToVetoed means a ConfirmDownStep callback refused a step.
+	DownToVetoed DownToErrorKind = "vetoed"
+	// DownToStepFailed means a migration's Down returned an error.
+	DownToStepFailed DownToErrorKind = "step_failed"
+)
+
+// DownToError is returned by DownTo when it stops for one of the reasons in
+// DownToErrorKind, including the unsurprising case of already being at
+// targetVersion when called. Cancellation of ctx, a failure to read the
+// current version and an error from a ConfirmDownStep are returned as they
+// are, not wrapped in a DownToError.
+type DownToError struct {
+	Kind    DownToErrorKind
+	Version int64
+	Err     error
+}
+
+func (e *DownToError) Error() string {
+	switch e.Kind {
+	case DownToAlreadyAtTarget:
+		return fmt.Sprintf("migrations: already at version %d", e.Version)
+	case DownToUnknownTarget:
+		return fmt.Sprintf("migrations: target version %d is not a registered migration", e.Version)
+	case DownToVetoed:
+		return fmt.Sprintf("migrations: down step to version %d was vetoed", e.Version)
+	case DownToStepFailed:
+		return fmt.Sprintf("migrations: down step failed at version %d: %s", e.Version, e.Err)
+	default:
+		return fmt.Sprintf("migrations: down migration error at version %d: %s", e.Version, e.Err)
+	}
+}
+
+func (e *DownToError) Unwrap() error { return e.Err }
+
+// DownTo reverts migrations one at a time, newest first, stopping as soon
+// as the database reaches targetVersion. Unlike DownAll, which always
+// walks down to version 1 and treats "no current version" as success, it
+// validates targetVersion up front and reports exactly why it stopped. It
+// returns nil once it has reverted at least one step and reached
+// targetVersion.
+func DownTo(ctx context.Context, driver string, db *sql.DB, targetVersion int64, opts ...MigrationOption) error {
+	goose.SetDialect(driver)
+	cfg := newMigrationConfig(ctx, opts)
+	log := cfg.logger
+
+	sorted := sortAndConnectMigrations(migrations)
+	if targetVersion != 0 {
+		found := false
+		for _, m := range sorted {
+			if m.Version == targetVersion {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &DownToError{Kind: DownToUnknownTarget, Version: targetVersion}
+		}
+	}
+
+	reverted := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		current, err := CurrentVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		if current <= targetVersion {
+			if reverted {
+				return nil
+			}
+			return &DownToError{Kind: DownToAlreadyAtTarget, Version: current}
+		}
+
+		step, err := sorted.Current(current)
+		if err != nil {
+			return &DownToError{Kind: DownToStepFailed, Version: current, Err: err}
+		}
+
+		if cfg.confirm != nil {
+			ok, err := cfg.confirm(ctx, step, targetVersion)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return &DownToError{Kind: DownToVetoed, Version: step.Version}
+			}
+		}
+
+		if err := runMigrationStep(ctx, db, step, false); err != nil {
+			return &DownToError{Kind: DownToStepFailed, Version: step.Version, Err: err}
+		}
+		reverted = true
+		if cfg.verbose {
+			log.Infof("goose: reverted migration %d (%s)", step.Version, step.Source)
+		}
+	}
+}
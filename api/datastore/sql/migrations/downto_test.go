@@ -0,0 +1,190 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pressly/goose"
+)
+
+func newSQLiteTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatalf("set dialect: %v", err)
+	}
+	if _, err := goose.GetDBVersion(db); err != nil {
+		t.Fatalf("bootstrap goose_db_version: %v", err)
+	}
+	return db
+}
+
+// withTestMigrations swaps in a fake, in-memory migration set for the
+// duration of a test and restores the package's real one afterward.
+func withTestMigrations(t *testing.T, fake goose.Migrations) {
+	t.Helper()
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = fake
+}
+
+func fakeMigration(version int64, down func(tx *sql.Tx) error) *goose.Migration {
+	return &goose.Migration{
+		Version:    version,
+		Next:       -1,
+		Previous:   -1,
+		Source:     fmt.Sprintf("%d_test.go", version),
+		Registered: true,
+		UpFn:       func(tx *sql.Tx) error { return nil },
+		DownFn:     down,
+	}
+}
+
+func applyTestMigrations(t *testing.T, db *sql.DB, upTo int64) {
+	t.Helper()
+	for _, m := range sortAndConnectMigrations(migrations) {
+		if m.Version > upTo {
+			break
+		}
+		if err := m.Up(db); err != nil {
+			t.Fatalf("apply migration %d: %v", m.Version, err)
+		}
+	}
+}
+
+func TestDownTo(t *testing.T) {
+	errDownFailed := errors.New("boom")
+
+	cases := []struct {
+		name          string
+		fake          goose.Migrations
+		appliedUpTo   int64
+		targetVersion int64
+		confirm       ConfirmDownStep
+		wantKind      DownToErrorKind
+		wantVersion   int64
+	}{
+		{
+			name: "already at target",
+			fake: goose.Migrations{
+				fakeMigration(1, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(2, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(3, func(tx *sql.Tx) error { return nil }),
+			},
+			appliedUpTo:   2,
+			targetVersion: 2,
+			wantKind:      DownToAlreadyAtTarget,
+			wantVersion:   2,
+		},
+		{
+			name: "unknown target",
+			fake: goose.Migrations{
+				fakeMigration(1, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(2, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(3, func(tx *sql.Tx) error { return nil }),
+			},
+			appliedUpTo:   3,
+			targetVersion: 42,
+			wantKind:      DownToUnknownTarget,
+			wantVersion:   42,
+		},
+		{
+			name: "vetoed",
+			fake: goose.Migrations{
+				fakeMigration(1, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(2, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(3, func(tx *sql.Tx) error { return nil }),
+			},
+			appliedUpTo:   3,
+			targetVersion: 1,
+			confirm: func(ctx context.Context, step *goose.Migration, target int64) (bool, error) {
+				return false, nil
+			},
+			wantKind:    DownToVetoed,
+			wantVersion: 3,
+		},
+		{
+			name: "step failed",
+			fake: goose.Migrations{
+				fakeMigration(1, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(2, func(tx *sql.Tx) error { return nil }),
+				fakeMigration(3, func(tx *sql.Tx) error { return errDownFailed }),
+			},
+			appliedUpTo:   3,
+			targetVersion: 1,
+			wantKind:      DownToStepFailed,
+			wantVersion:   3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			withTestMigrations(t, tc.fake)
+			db := newSQLiteTestDB(t)
+			applyTestMigrations(t, db, tc.appliedUpTo)
+
+			var opts []MigrationOption
+			if tc.confirm != nil {
+				opts = append(opts, WithConfirm(tc.confirm))
+			}
+
+			err := DownTo(context.Background(), "sqlite3", db, tc.targetVersion, opts...)
+			if err == nil {
+				t.Fatalf("DownTo() = nil error, want %s", tc.wantKind)
+			}
+
+			var downErr *DownToError
+			if !errors.As(err, &downErr) {
+				t.Fatalf("DownTo() error = %v, want *DownToError", err)
+			}
+			if downErr.Kind != tc.wantKind {
+				t.Errorf("Kind = %s, want %s", downErr.Kind, tc.wantKind)
+			}
+			if downErr.Version != tc.wantVersion {
+				t.Errorf("Version = %d, want %d", downErr.Version, tc.wantVersion)
+			}
+		})
+	}
+}
+
+func TestMigrationsUpTo(t *testing.T) {
+	plan := goose.Migrations{
+		fakeMigration(1, nil),
+		fakeMigration(2, nil),
+		fakeMigration(3, nil),
+	}
+
+	cases := []struct {
+		name          string
+		targetVersion int64
+		wantVersions  []int64
+	}{
+		{"no target keeps everything", noTargetVersion, []int64{1, 2, 3}},
+		{"target mid-plan trims after it", 2, []int64{1, 2}},
+		{"target matches last entry", 3, []int64{1, 2, 3}},
+		{"target below everything trims to empty", 0, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := migrationsUpTo(plan, tc.targetVersion)
+			if len(got) != len(tc.wantVersions) {
+				t.Fatalf("migrationsUpTo() = %d entries, want %d", len(got), len(tc.wantVersions))
+			}
+			for i, m := range got {
+				if m.Version != tc.wantVersions[i] {
+					t.Errorf("entry %d version = %d, want %d", i, m.Version, tc.wantVersions[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package migrations
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// migrationLockNamespace names the migration lock: it is hashed into the
+// Postgres advisory lock key and used as is for the MySQL lock name. It is
+// the same for every fn database. Postgres advisory locks are scoped to the
+// current database, but MySQL lock names are server-wide, so on MySQL fn
+// instances pointed at different databases on one server also wait for
+// each other.
+const migrationLockNamespace = "fn_migrations"
+
+// sqliteLockPollInterval is how often an SQLite instance re-checks the
+// sentinel lock row while waiting for another instance to finish migrating.
+const sqliteLockPollInterval = 100 * time.Millisecond
+
+// sqliteLockStaleAfter bounds how long a held SQLite sentinel lock is
+// trusted. Postgres/MySQL locks are tied to a session, so they're released
+// automatically if the holder's connection dies (crash, OOM kill, deploy
+// rollback); our hand-rolled SQLite row has no such safety net, so a waiter
+// is allowed to steal a lock that's been held longer than this without
+// being released. Keep this comfortably longer than the slowest migration
+// this deployment expects to run, since a legitimately slow holder that
+// exceeds it can have its lock stolen out from under it.
+const sqliteLockStaleAfter = 5 * time.Minute
+
+func migrationLockID() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(migrationLockNamespace))
+	return int64(h.Sum64())
+}
+
+// acquireMigrationLock blocks until this instance holds the exclusive right
+// to run migrations, so that multiple fn replicas starting up at once don't
+// race on goose_db_version. The returned release func must be called
+// exactly once, even on panic, to let the next waiter in.
+func acquireMigrationLock(ctx context.Context, driver string, db *sql.DB) (release func() error, err error) {
+	switch driver {
+	case "postgres":
+		return acquirePostgresMigrationLock(ctx, db)
+	case "mysql":
+		return acquireMySQLMigrationLock(ctx, db)
+	case "sqlite3":
+		return acquireSQLiteMigrationLock(ctx, db)
+	default:
+		return nil, fmt.Errorf("migrations: unsupported driver %q", driver)
+	}
+}
+
+// pinnedConnRelease closes conn after running the unlock statement on it.
+// pg_advisory_unlock/RELEASE_LOCK only release the lock held by the calling
+// session, so the unlock must run on the exact connection that took the
+// lock rather than on a fresh one handed back by the pool.
+func pinnedConnRelease(conn *sql.Conn, query string, args ...interface{}) func() error {
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), query, args...)
+		return err
+	}
+}
+
+func acquirePostgresMigrationLock(ctx context.Context, db *sql.DB) (func() error, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockID := migrationLockID()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return pinnedConnRelease(conn, "SELECT pg_advisory_unlock($1)", lockID), nil
+}
+
+func acquireMySQLMigrationLock(ctx context.Context, db *sql.DB) (func() error, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockName := migrationLockNamespace
+	var got int
+	// timeout of -1 means wait indefinitely, same as pg_advisory_lock; ctx
+	// cancellation is still observed because ExecContext/QueryRowContext
+	// abort the query when ctx is done.
+	row := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", lockName)
+	if err := row.Scan(&got); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if got != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("migrations: could not acquire MySQL lock %q", lockName)
+	}
+	return pinnedConnRelease(conn, "SELECT RELEASE_LOCK(?)", lockName), nil
+}
+
+// newLockToken generates a value unique to this acquisition, so release
+// only clears the row if it still holds the lock it originally took. That
+// matters once leases can be stolen: if this instance was merely slow
+// rather than dead, its eventual release() must not clobber whatever later
+// instance stole the lease out from under it.
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// acquireSQLiteMigrationLock polls a sentinel row instead of using an
+// advisory lock, since SQLite has no such primitive. A lease that's older
+// than sqliteLockStaleAfter can be stolen by a waiter, so a crashed holder
+// that never released doesn't block every future instance forever.
+func acquireSQLiteMigrationLock(ctx context.Context, db *sql.DB) (func() error, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS fn_migration_lock (
+	id INTEGER NOT NULL PRIMARY KEY CHECK (id = 1),
+	locked INTEGER NOT NULL,
+	token TEXT,
+	acquired_at TIMESTAMP
+);`); err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO fn_migration_lock (id, locked) VALUES (1, 0)`); err != nil {
+		return nil, err
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	staleAfter := fmt.Sprintf("-%d seconds", int(sqliteLockStaleAfter.Seconds()))
+
+	for {
+		res, err := db.ExecContext(ctx, `UPDATE fn_migration_lock
+			SET locked = 1, token = ?, acquired_at = CURRENT_TIMESTAMP
+			WHERE id = 1 AND (locked = 0 OR acquired_at <= datetime('now', ?))`,
+			token, staleAfter)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sqliteLockPollInterval):
+		}
+	}
+
+	return func() error {
+		_, err := db.ExecContext(context.Background(),
+			`UPDATE fn_migration_lock SET locked = 0, token = NULL WHERE id = 1 AND token = ?`, token)
+		return err
+	}, nil
+}
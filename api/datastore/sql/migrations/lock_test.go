@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLiteLockBlocksSecondAcquire(t *testing.T) {
+	db := newSQLiteTestDB(t)
+
+	release1, err := acquireSQLiteMigrationLock(context.Background(), db)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		release2, err := acquireSQLiteMigrationLock(context.Background(), db)
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- release2()
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("second acquire should have blocked while first holds the lock")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if err := release1(); err != nil {
+		t.Fatalf("release1: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("second acquire/release: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second acquire never completed after release")
+	}
+}
+
+func TestSQLiteLockStealsStaleLease(t *testing.T) {
+	db := newSQLiteTestDB(t)
+
+	release1, err := acquireSQLiteMigrationLock(context.Background(), db)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	_ = release1 // simulate a crash: don't call release1 before the lease is stolen
+
+	// Backdate the lease so it looks stale without waiting out the real TTL.
+	if _, err := db.Exec(`UPDATE fn_migration_lock SET acquired_at = datetime('now', '-1 hour') WHERE id = 1`); err != nil {
+		t.Fatalf("backdate lease: %v", err)
+	}
+
+	release2, err := acquireSQLiteMigrationLock(context.Background(), db)
+	if err != nil {
+		t.Fatalf("second acquire should have stolen the stale lease: %v", err)
+	}
+
+	// The dead holder's release must not be able to clobber the new holder.
+	if err := release1(); err != nil {
+		t.Fatalf("stale release1: %v", err)
+	}
+	var locked int
+	if err := db.QueryRow(`SELECT locked FROM fn_migration_lock WHERE id = 1`).Scan(&locked); err != nil {
+		t.Fatal(err)
+	}
+	if locked != 1 {
+		t.Fatalf("stale release1 incorrectly released the new holder's lock")
+	}
+
+	if err := release2(); err != nil {
+		t.Fatalf("release2: %v", err)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"github.com/fnproject/fn/api/common"
 	"github.com/pressly/goose"
+	"github.com/sirupsen/logrus"
 	"sort"
+	"time"
 )
 
 var (
@@ -58,9 +60,71 @@ var tables = [...]string{`CREATE TABLE IF NOT EXISTS routes (
 );`,
 }
 
-func checkOldMigrationTableVersionIfExists(db *sql.DB) (version int64, dirty bool, err error) {
+// noTargetVersion means "apply everything that's pending" rather than
+// stopping at a specific version.
+const noTargetVersion int64 = -1
+
+type migrationConfig struct {
+	logger        logrus.FieldLogger
+	verbose       bool
+	dryRun        bool
+	targetVersion int64
+	confirm       ConfirmDownStep
+}
+
+// MigrationOption configures ApplyMigrations. Options are applied in order,
+// so later options win if they touch the same field.
+type MigrationOption func(*migrationConfig)
+
+// WithLogger overrides the logger ApplyMigrations reports progress to.
+// Defaults to common.Logger(ctx). The check of the old schema_migrations
+// table always logs to common.Logger(ctx).
+func WithLogger(logger logrus.FieldLogger) MigrationOption {
+	return func(c *migrationConfig) { c.logger = logger }
+}
+
+// WithVerbose logs timing for every applied migration, in addition to the
+// handful of Debug lines emitted by default.
+func WithVerbose(verbose bool) MigrationOption {
+	return func(c *migrationConfig) { c.verbose = verbose }
+}
+
+// WithDryRun resolves the migration plan and logs it without running any
+// migration's Up, creating the bootstrap tables, or taking the migration
+// lock. Useful for previewing what a deploy would change. Note that goose
+// itself still creates its own goose_db_version bookkeeping table on first
+// use if it doesn't exist yet, since there's no way to read a version
+// without it.
+func WithDryRun(dryRun bool) MigrationOption {
+	return func(c *migrationConfig) { c.dryRun = dryRun }
+}
+
+// WithTargetVersion stops the upgrade at the given version instead of
+// applying every pending migration.
+func WithTargetVersion(version int64) MigrationOption {
+	return func(c *migrationConfig) { c.targetVersion = version }
+}
+
+// WithConfirm registers a callback DownTo invokes before each destructive
+// down step, so a caller can veto based on row counts or environment (e.g.
+// refuse to run in production). Has no effect on ApplyMigrations.
+func WithConfirm(confirm ConfirmDownStep) MigrationOption {
+	return func(c *migrationConfig) { c.confirm = confirm }
+}
+
+func newMigrationConfig(ctx context.Context, opts []MigrationOption) *migrationConfig {
+	c := &migrationConfig{
+		logger:        common.Logger(ctx),
+		targetVersion: noTargetVersion,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func checkOldMigrationTableVersionIfExists(ctx context.Context, db *sql.DB) (version int64, dirty bool, err error) {
 	migrationsTable := "schema_migrations"
-	ctx := context.Background()
 
 	q := db.QueryRowContext(
 		ctx, "SELECT version, dirty FROM "+migrationsTable+" LIMIT 1")
@@ -91,11 +155,32 @@ func sortAndConnectMigrations(migrations goose.Migrations) goose.Migrations {
 	return migrations
 }
 
-func DownAll(driver string, db *sql.DB) error {
+// runMigrationStep runs a single migration direction, refusing to start one
+// once ctx is already done. goose's Migration.Up/Down only take a *sql.DB
+// and commit via their own internal transaction, so once a step has started
+// there's no way for us to cancel or roll it back mid-flight: this only
+// stops a SIGINT or deadline from starting the *next* step in the chain,
+// it cannot abort or undo one already in progress.
+func runMigrationStep(ctx context.Context, db *sql.DB, m *goose.Migration, up bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if up {
+		return m.Up(db)
+	}
+	return m.Down(db)
+}
+
+func DownAll(ctx context.Context, driver string, db *sql.DB) error {
 	goose.SetDialect(driver)
 	migrations = sortAndConnectMigrations(migrations)
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		currentVersion, err := goose.GetDBVersion(db)
 		if err != nil {
 			return err
@@ -112,7 +197,7 @@ func DownAll(driver string, db *sql.DB) error {
 			return nil
 		}
 
-		if err = current.Down(db); err != nil {
+		if err = runMigrationStep(ctx, db, current, false); err != nil {
 			return err
 		}
 	}
@@ -122,7 +207,7 @@ func DownAll(driver string, db *sql.DB) error {
 func checkOldMigration(ctx context.Context, db *sql.DB) (int64, goose.Migrations, error) {
 	log := common.Logger(ctx)
 	migrationsSorted := sortAndConnectMigrations(migrations)
-	current, dirty, err := checkOldMigrationTableVersionIfExists(db)
+	current, dirty, err := checkOldMigrationTableVersionIfExists(ctx, db)
 	if err != nil {
 		return -1, nil, err
 	}
@@ -139,11 +224,52 @@ func checkOldMigration(ctx context.Context, db *sql.DB) (int64, goose.Migrations
 	return -1, migrationsSorted, nil
 }
 
-func ApplyMigrations(ctx context.Context, driver string, db *sql.DB) error {
+// migrationsUpTo trims a migration plan to stop at targetVersion, or returns
+// it unchanged when no target was requested.
+func migrationsUpTo(plan goose.Migrations, targetVersion int64) goose.Migrations {
+	if targetVersion == noTargetVersion {
+		return plan
+	}
+	for i, m := range plan {
+		if m.Version > targetVersion {
+			return plan[:i]
+		}
+	}
+	return plan
+}
+
+func ApplyMigrations(ctx context.Context, driver string, db *sql.DB, opts ...MigrationOption) error {
 	goose.SetDialect(driver)
-	log := common.Logger(ctx)
+	cfg := newMigrationConfig(ctx, opts)
+	log := cfg.logger
+
+	dq, err := dialectQueriesFor(driver)
+	if err != nil {
+		return err
+	}
+
+	if cfg.dryRun {
+		return previewMigrations(ctx, db, cfg)
+	}
+
+	// Only one replica should run migrations at a time; the rest block here
+	// until the lock holder is done, then re-read the DB version below and
+	// find there's nothing left to do.
+	release, err := acquireMigrationLock(ctx, driver, db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			release()
+			panic(p)
+		}
+		if relErr := release(); relErr != nil {
+			log.Error("migrations: failed to release migration lock: ", relErr.Error())
+		}
+	}()
 
-	for _, v := range tables {
+	for _, v := range dq.CreateTableStatements() {
 		_, err := db.ExecContext(ctx, v)
 		if err != nil {
 			return err
@@ -170,12 +296,21 @@ func ApplyMigrations(ctx context.Context, driver string, db *sql.DB) error {
 
 	// do not run the migrations if goose version is higher than old migrate version
 	if gooseCurrent < migrateCurrent {
+		left = migrationsUpTo(left, cfg.targetVersion)
 		log.Debug("migrations to apply: ", len(left))
+
 		for _, m := range left {
-			if err := m.Up(db); err != nil {
+			start := time.Now()
+			if err := runMigrationStep(ctx, db, m, true); err != nil {
 				log.Error("migrations upgrade error: ", err.Error())
 				return err
 			}
+			if cfg.verbose {
+				// goose.Migration.Up doesn't surface a sql.Result, so we can't
+				// report rows affected here without forking its runner; log
+				// what we do have.
+				log.Infof("goose: applied migration %d (%s) in %s", m.Version, m.Source, time.Since(start))
+			}
 		}
 		log.Debug("goose: next datastore will be: ", migrateCurrent+1)
 	}
@@ -183,3 +318,32 @@ func ApplyMigrations(ctx context.Context, driver string, db *sql.DB) error {
 
 	return nil
 }
+
+// previewMigrations resolves and logs the plan ApplyMigrations would run,
+// without taking the migration lock, creating the bootstrap tables, or
+// applying anything. The only change it can make to db is goose creating
+// its goose_db_version table when that table doesn't exist yet.
+func previewMigrations(ctx context.Context, db *sql.DB, cfg *migrationConfig) error {
+	log := cfg.logger
+
+	gooseCurrent, err := goose.GetDBVersion(db)
+	if err != nil && err != goose.ErrNoNextVersion {
+		return err
+	}
+
+	migrateCurrent, left, err := checkOldMigration(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if gooseCurrent >= migrateCurrent {
+		log.Info("goose: dry run: nothing to apply")
+		return nil
+	}
+
+	left = migrationsUpTo(left, cfg.targetVersion)
+	for _, m := range left {
+		log.Infof("goose: would apply migration %d (%s)", m.Version, m.Source)
+	}
+	return nil
+}
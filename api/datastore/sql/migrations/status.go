@@ -0,0 +1,104 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pressly/goose"
+)
+
+// MigrationStatus describes one registered migration's state relative to
+// the database driver/db is pointed at.
+type MigrationStatus struct {
+	Version int64
+	Source  string
+	Applied bool
+	// AppliedAt is the zero time when the migration hasn't been applied yet.
+	AppliedAt time.Time
+
+	// OldMigrationVersion and OldMigrationDirty mirror
+	// checkOldMigrationTableVersionIfExists, so a deployment that's mid-way
+	// through moving from the old migrate-based schema_migrations table to
+	// goose can be told apart from a clean goose-only one. They're the same
+	// on every entry in a given Status call.
+	OldMigrationVersion int64
+	OldMigrationDirty   bool
+}
+
+// CurrentVersion returns the schema version goose_db_version reports for db.
+func CurrentVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+	return goose.GetDBVersion(db)
+}
+
+// appliedAtByVersion looks up when each already-applied migration ran, by
+// reading goose's own bookkeeping table directly. Pending migrations simply
+// won't have an entry. A version can have several rows if it was reverted
+// and reapplied, so rows are read oldest-first: an is_applied row records
+// its timestamp and a later revert row removes it again, leaving the most
+// recent application of each version that is still applied.
+func appliedAtByVersion(ctx context.Context, db *sql.DB) (map[int64]time.Time, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY tstamp, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &isApplied, &appliedAt); err != nil {
+			return nil, err
+		}
+		if isApplied {
+			applied[version] = appliedAt
+		} else {
+			delete(applied, version)
+		}
+	}
+	return applied, rows.Err()
+}
+
+// Status reports the applied/pending state of every registered migration,
+// plus whatever old pre-goose migration bookkeeping still exists, so mixed
+// old-migrate + goose deployments can be diagnosed without shelling into
+// the database to query goose_db_version by hand.
+func Status(ctx context.Context, driver string, db *sql.DB) ([]MigrationStatus, error) {
+	goose.SetDialect(driver)
+
+	current, err := CurrentVersion(ctx, db)
+	if err != nil && err != goose.ErrNoNextVersion {
+		return nil, err
+	}
+
+	oldVersion, dirty, err := checkOldMigrationTableVersionIfExists(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt, err := appliedAtByVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := sortAndConnectMigrations(migrations)
+	statuses := make([]MigrationStatus, 0, len(sorted))
+	for _, m := range sorted {
+		statuses = append(statuses, MigrationStatus{
+			Version:             m.Version,
+			Source:              m.Source,
+			Applied:             m.Version <= current,
+			AppliedAt:           appliedAt[m.Version],
+			OldMigrationVersion: oldVersion,
+			OldMigrationDirty:   dirty,
+		})
+	}
+
+	return statuses, nil
+}
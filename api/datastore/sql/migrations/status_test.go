@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose"
+)
+
+func TestStatusAndCurrentVersion(t *testing.T) {
+	withTestMigrations(t, goose.Migrations{
+		fakeMigration(1, func(tx *sql.Tx) error { return nil }),
+		fakeMigration(2, func(tx *sql.Tx) error { return nil }),
+	})
+	db := newSQLiteTestDB(t)
+	ctx := context.Background()
+
+	applyTestMigrations(t, db, 2)
+
+	current, err := CurrentVersion(ctx, db)
+	if err != nil {
+		t.Fatalf("CurrentVersion: %v", err)
+	}
+	if current != 2 {
+		t.Fatalf("CurrentVersion = %d, want 2", current)
+	}
+
+	// Revert version 2 and reapply it, so goose_db_version ends up with two
+	// is_applied rows for version 2. Status must report the latest one, not
+	// whichever row the query happens to return first.
+	sorted := sortAndConnectMigrations(migrations)
+	v2, err := sorted.Current(2)
+	if err != nil {
+		t.Fatalf("sorted.Current(2): %v", err)
+	}
+	if err := v2.Down(db); err != nil {
+		t.Fatalf("revert version 2: %v", err)
+	}
+	if err := v2.Up(db); err != nil {
+		t.Fatalf("reapply version 2: %v", err)
+	}
+
+	statuses, err := Status(ctx, "sqlite3", db)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Status returned %d entries, want 2", len(statuses))
+	}
+
+	var gotV2 MigrationStatus
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("version %d: Applied = false, want true", s.Version)
+		}
+		if s.AppliedAt.IsZero() {
+			t.Errorf("version %d: AppliedAt is zero, want the application timestamp", s.Version)
+		}
+		if s.Version == 2 {
+			gotV2 = s
+		}
+	}
+
+	var wantAppliedAt time.Time
+	row := db.QueryRow(`SELECT tstamp FROM goose_db_version
+		WHERE version_id = 2 AND is_applied = 1 ORDER BY id DESC LIMIT 1`)
+	if err := row.Scan(&wantAppliedAt); err != nil {
+		t.Fatalf("read latest goose_db_version row: %v", err)
+	}
+	if !gotV2.AppliedAt.Equal(wantAppliedAt) {
+		t.Errorf("version 2 AppliedAt = %v, want the reapply's timestamp %v", gotV2.AppliedAt, wantAppliedAt)
+	}
+}